@@ -5,18 +5,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"regexp"
-	"text/template"
+	"strconv"
 	"time"
 )
 
 const SOFTLAYER_API_URL = "api.softlayer.com/rest/v3"
 
+// Defaults for SoftlayerClient's retry policy, used when New() is called
+// instead of setting MaxRetries/RetryBaseDelay/RetryMaxDelay directly.
+const (
+	DefaultMaxRetries     = 8
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
 type SoftlayerClient struct {
 	// The http client for communicating
 	http *http.Client
@@ -24,6 +34,21 @@ type SoftlayerClient struct {
 	// Credentials
 	user   string
 	apiKey string
+
+	// Retry policy for doRawHttpRequest. GETs are retried unconditionally on
+	// failure; POST/DELETE are only retried on network-level errors or
+	// explicit 429/503 responses, since they aren't guaranteed idempotent.
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// BlockDevice describes an extra disk to attach to an order, landing in a
+// virtual guest order's `blockDevices` list or a hardware order's
+// `hardDrives` list.
+type BlockDevice struct {
+	Device   string
+	Capacity int
 }
 
 // Based on: http://sldn.softlayer.com/reference/datatypes/SoftLayer_Container_Virtual_Guest_Configuration/
@@ -40,9 +65,47 @@ type InstanceType struct {
 	ProvisioningSshKeyId float64
 	BaseImageId          string
 	BaseOsCode           string
+
+	// Fields below are only consumed by PlaceOrder.
+	BlockDevices           []BlockDevice
+	PublicVlanId           int
+	PrivateVlanId          int
+	PrivateNetworkOnlyFlag bool
+	PostInstallScriptUri   string
+}
+
+// DefaultHardwareReadyTimeout is how long waitForHardwareReady waits by
+// default: baremetal provisioning routinely takes 1-4 hours, unlike virtual
+// guests which are usually ready in minutes.
+const DefaultHardwareReadyTimeout = 4 * time.Hour
+
+// Based on: http://sldn.softlayer.com/reference/datatypes/SoftLayer_Hardware/
+type HardwareType struct {
+	HostName             string
+	Domain               string
+	Datacenter           string
+	PackageId            int
+	PresetId             int
+	ProcessorCoreAmount  int
+	Memory               int64
+	HardDrives           []BlockDevice
+	HourlyBillingFlag    bool
+	ProvisioningSshKeyId float64
+	BaseOsCode           string
 }
 
+// New builds a SoftlayerClient. An empty user or key falls back to the
+// SOFTLAYER_USERNAME / SOFTLAYER_API_KEY environment variables, the
+// convention used by the SoftLayer terraform provider, so callers don't have
+// to hardcode credentials in the packer template.
 func (self SoftlayerClient) New(user string, key string) *SoftlayerClient {
+	if user == "" {
+		user = os.Getenv("SOFTLAYER_USERNAME")
+	}
+	if key == "" {
+		key = os.Getenv("SOFTLAYER_API_KEY")
+	}
+
 	return &SoftlayerClient{
 		http: &http.Client{
 			Transport: &http.Transport{
@@ -51,66 +114,302 @@ func (self SoftlayerClient) New(user string, key string) *SoftlayerClient {
 		},
 		user:   user,
 		apiKey: key,
+
+		MaxRetries:     DefaultMaxRetries,
+		RetryBaseDelay: DefaultRetryBaseDelay,
+		RetryMaxDelay:  DefaultRetryMaxDelay,
 	}
 }
 
-func (self SoftlayerClient) generateRequestBody(templatePath string, templateData interface{}) *bytes.Buffer {
-	cwd, _ := os.Getwd()
-	bodyTemplate := template.Must(template.ParseFiles(filepath.Join(cwd, templatePath)))
-	body := new(bytes.Buffer)
-	bodyTemplate.Execute(body, templateData)
+// SoftLayer_Virtual_Guest_Template_Parameters is the body of a
+// SoftLayer_Virtual_Guest/createObject call.
+type SoftLayer_Virtual_Guest_Template_Parameters struct {
+	Parameters []softLayerVirtualGuestTemplate `json:"parameters"`
+}
 
-	log.Printf("Generated request body %s", body)
+type softLayerVirtualGuestTemplate struct {
+	Hostname                     string                      `json:"hostname"`
+	Domain                       string                      `json:"domain"`
+	StartCpus                    int                         `json:"startCpus"`
+	MaxMemory                    int64                       `json:"maxMemory"`
+	Datacenter                   softLayerLocation           `json:"datacenter"`
+	HourlyBillingFlag            bool                        `json:"hourlyBillingFlag"`
+	LocalDiskFlag                bool                        `json:"localDiskFlag"`
+	NetworkComponents            []softLayerNetworkComponent `json:"networkComponents"`
+	SshKeys                      []softLayerIdReference      `json:"sshKeys,omitempty"`
+	BlockDeviceTemplateGroup     *softLayerGlobalIdentifier  `json:"blockDeviceTemplateGroup,omitempty"`
+	OperatingSystemReferenceCode string                      `json:"operatingSystemReferenceCode,omitempty"`
+}
 
-	return body
+type softLayerLocation struct {
+	Name string `json:"name"`
 }
 
-func (self SoftlayerClient) doRawHttpRequest(path string, requestType string, requestBody *bytes.Buffer) ([]byte, error) {
-	url := fmt.Sprintf("https://%s:%s@%s/%s", self.user, self.apiKey, SOFTLAYER_API_URL, path)
-	log.Printf("Sending new request to softlayer: %s", url)
+type softLayerNetworkComponent struct {
+	MaxSpeed int `json:"maxSpeed"`
+}
 
-	// Create the request object
-	var lastResponse http.Response
-	switch requestType {
-	case "POST", "DELETE":
-		req, err := http.NewRequest(requestType, url, requestBody)
+type softLayerIdReference struct {
+	Id float64 `json:"id"`
+}
+
+type softLayerGlobalIdentifier struct {
+	GlobalIdentifier string `json:"globalIdentifier"`
+}
+
+// SoftLayer_Shh_Key_Parameters is the body of a
+// SoftLayer_Security_Ssh_Key/createObject call.
+type SoftLayer_Shh_Key_Parameters struct {
+	Parameters []softLayerSecuritySshKey `json:"parameters"`
+}
+
+type softLayerSecuritySshKey struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// SoftLayer_Container_Disk_Image_Capture_Template is the body of a
+// SoftLayer_Virtual_Guest/captureImage call.
+type SoftLayer_Container_Disk_Image_Capture_Template struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// SoftLayer_Container_Product_Order_Virtual_Guest is the body of a
+// SoftLayer_Product_Order/placeOrder call for a VIRTUAL_SERVER_INSTANCE
+// package, used by PlaceOrder.
+type SoftLayer_Container_Product_Order_Virtual_Guest struct {
+	Parameters []softLayerProductOrder `json:"parameters"`
+}
 
-		if err != nil {
-			return nil, err
+type softLayerProductOrder struct {
+	ComplexType      string                       `json:"complexType"`
+	PackageType      string                       `json:"packageType"`
+	Quantity         int                          `json:"quantity"`
+	UseHourlyPricing bool                         `json:"useHourlyPricing"`
+	VirtualGuests    []softLayerOrderVirtualGuest `json:"virtualGuests"`
+}
+
+type softLayerOrderVirtualGuest struct {
+	Hostname                       string                          `json:"hostname"`
+	Domain                         string                          `json:"domain"`
+	StartCpus                      int                             `json:"startCpus"`
+	MaxMemory                      int64                           `json:"maxMemory"`
+	Datacenter                     softLayerLocation               `json:"datacenter"`
+	LocalDiskFlag                  bool                            `json:"localDiskFlag"`
+	PrivateNetworkOnlyFlag         bool                            `json:"privateNetworkOnlyFlag"`
+	PostInstallScriptUri           string                          `json:"postInstallScriptUri,omitempty"`
+	NetworkComponents              []softLayerNetworkComponent     `json:"networkComponents"`
+	PrimaryNetworkComponent        *softLayerOrderNetworkComponent `json:"primaryNetworkComponent,omitempty"`
+	PrimaryBackendNetworkComponent *softLayerOrderNetworkComponent `json:"primaryBackendNetworkComponent,omitempty"`
+	SshKeys                        []softLayerIdReference          `json:"sshKeys,omitempty"`
+	BlockDevices                   []softLayerOrderBlockDevice     `json:"blockDevices"`
+	OperatingSystemReferenceCode   string                          `json:"operatingSystemReferenceCode,omitempty"`
+}
+
+type softLayerOrderNetworkComponent struct {
+	NetworkVlan softLayerIdReference `json:"networkVlan"`
+}
+
+type softLayerOrderBlockDevice struct {
+	Device    string                     `json:"device"`
+	DiskImage softLayerDiskImageCapacity `json:"diskImage"`
+}
+
+type softLayerDiskImageCapacity struct {
+	Capacity int `json:"capacity"`
+}
+
+// SoftLayer_Container_Product_Order_Hardware_Server is the body of a
+// SoftLayer_Product_Order/placeOrder call for a BARE_METAL_CORE package,
+// used by CreateHardware.
+type SoftLayer_Container_Product_Order_Hardware_Server struct {
+	Parameters []softLayerHardwareProductOrder `json:"parameters"`
+}
+
+type softLayerHardwareProductOrder struct {
+	ComplexType string                   `json:"complexType"`
+	PackageType string                   `json:"packageType"`
+	PackageId   int                      `json:"packageId"`
+	PresetId    int                      `json:"presetId"`
+	Quantity    int                      `json:"quantity"`
+	Hardware    []softLayerOrderHardware `json:"hardware"`
+}
+
+type softLayerOrderHardware struct {
+	Hostname                     string                      `json:"hostname"`
+	Domain                       string                      `json:"domain"`
+	Datacenter                   softLayerLocation           `json:"datacenter"`
+	ProcessorCoreAmount          int                         `json:"processorCoreAmount,omitempty"`
+	MemoryCapacity               int64                       `json:"memoryCapacity,omitempty"`
+	HourlyBillingFlag            bool                        `json:"hourlyBillingFlag"`
+	SshKeys                      []softLayerIdReference      `json:"sshKeys,omitempty"`
+	HardDrives                   []softLayerOrderBlockDevice `json:"hardDrives,omitempty"`
+	OperatingSystemReferenceCode string                      `json:"operatingSystemReferenceCode,omitempty"`
+}
+
+func (self SoftlayerClient) marshalRequestBody(v interface{}) (*bytes.Buffer, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to marshal SoftLayer request body: %s", err))
+	}
+
+	log.Printf("Generated request body %s", encoded)
+
+	return bytes.NewBuffer(encoded), nil
+}
+
+// SoftlayerAPIError represents the `{"error":"...","code":"..."}` envelope
+// SoftLayer returns on failed calls. Callers can use errors.As to recover it.
+type SoftlayerAPIError struct {
+	HttpStatusCode int
+	Message        string
+	Code           string
+}
+
+func (self *SoftlayerAPIError) Error() string {
+	return fmt.Sprintf("SoftLayer API error (HTTP %d, code %s): %s", self.HttpStatusCode, self.Code, self.Message)
+}
+
+// IsHttpErrorCode classifies HTTP status codes into the 4xx/5xx range that
+// SoftLayer uses to signal a failed request.
+func IsHttpErrorCode(statusCode int) bool {
+	return statusCode >= 400
+}
+
+// doRawHttpRequest wraps doRawHttpRequestOnce with exponential backoff +
+// jitter, retrying idempotent GETs unconditionally and POST/DELETE only on
+// network-level errors or explicit 429/503 responses (SoftLayer's "please
+// retry" signals), honoring Retry-After when the response sends one.
+func (self SoftlayerClient) doRawHttpRequest(path string, requestType string, requestBody *bytes.Buffer) ([]byte, int, error) {
+	var bodyBytes []byte
+	if requestBody != nil {
+		bodyBytes = requestBody.Bytes()
+	}
+
+	var responseBody []byte
+	var statusCode int
+	var err error
+
+	for attempt := 0; ; attempt++ {
+		var attemptBody *bytes.Buffer
+		if bodyBytes != nil {
+			attemptBody = bytes.NewBuffer(bodyBytes)
 		}
-		resp, err := self.http.Do(req)
 
-		if err != nil {
-			return nil, err
-		} else {
-			lastResponse = *resp
+		var retryAfter time.Duration
+		responseBody, statusCode, retryAfter, err = self.doRawHttpRequestOnce(path, requestType, attemptBody)
+
+		if !self.shouldRetryRequest(requestType, statusCode, err) || attempt >= self.MaxRetries {
+			return responseBody, statusCode, err
 		}
-	case "GET":
-		resp, err := http.Get(url)
 
-		if err != nil {
-			return nil, err
-		} else {
-			lastResponse = *resp
+		delay := retryAfter
+		if delay == 0 {
+			delay = self.backoffDelay(attempt)
 		}
+
+		log.Printf("Retrying SoftLayer %s %s after %s (attempt %d/%d): statusCode=%d err=%v",
+			requestType, path, delay, attempt+1, self.MaxRetries, statusCode, err)
+		time.Sleep(delay)
+	}
+}
+
+// shouldRetryRequest decides whether a failed attempt is worth retrying,
+// per the idempotency rules documented on doRawHttpRequest.
+func (self SoftlayerClient) shouldRetryRequest(requestType string, statusCode int, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	// 429/503 are retried regardless of method; they're SoftLayer's explicit
+	// "please retry" signals. Beyond that, GETs additionally retry on any
+	// other 4xx/5xx response, which is safe since they're idempotent.
+	if statusCode == 429 || statusCode == 503 {
+		return true
+	}
+
+	if requestType == "GET" {
+		return IsHttpErrorCode(statusCode)
+	}
+
+	return false
+}
+
+// backoffDelay computes the exponential-backoff-plus-jitter delay for the
+// given (zero-indexed) retry attempt, bounded by RetryMaxDelay.
+func (self SoftlayerClient) backoffDelay(attempt int) time.Duration {
+	delay := self.RetryBaseDelay << uint(attempt)
+	if delay <= 0 || delay > self.RetryMaxDelay {
+		delay = self.RetryMaxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay) + 1))
+	return delay/2 + jitter/2
+}
+
+// parseRetryAfter parses the Retry-After header, which SoftLayer may send
+// as either a number of seconds or an HTTP-date.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+func (self SoftlayerClient) doRawHttpRequestOnce(path string, requestType string, requestBody *bytes.Buffer) ([]byte, int, time.Duration, error) {
+	url := fmt.Sprintf("https://%s/%s", SOFTLAYER_API_URL, path)
+	log.Printf("Sending new request to softlayer: %s %s", requestType, url)
+
+	switch requestType {
+	case "POST", "DELETE", "GET":
+		// handled below
 	default:
-		return nil, errors.New(fmt.Sprintf("Undefined request type '%s', only GET/POST/DELETE are available!", requestType))
+		return nil, 0, 0, errors.New(fmt.Sprintf("Undefined request type '%s', only GET/POST/DELETE are available!", requestType))
+	}
+
+	var body io.Reader
+	if requestBody != nil {
+		body = requestBody
+	}
+
+	req, err := http.NewRequest(requestType, url, body)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	req.SetBasicAuth(self.user, self.apiKey)
+
+	resp, err := self.http.Do(req)
+	if err != nil {
+		return nil, 0, 0, err
 	}
+	lastResponse := *resp
 
 	responseBody, err := ioutil.ReadAll(lastResponse.Body)
 	lastResponse.Body.Close()
 	if err != nil {
-		return nil, err
+		return nil, lastResponse.StatusCode, 0, err
 	}
 
-	log.Printf("Received response from SoftLayer: %s", responseBody)
-	return responseBody, nil
+	log.Printf("Received response from SoftLayer (HTTP %d): %s", lastResponse.StatusCode, responseBody)
+	return responseBody, lastResponse.StatusCode, parseRetryAfter(lastResponse.Header.Get("Retry-After")), nil
 }
 
 func (self SoftlayerClient) doHttpRequest(path string, requestType string, requestBody *bytes.Buffer) (map[string]interface{}, error) {
-	responseBody, err := self.doRawHttpRequest(path, requestType, requestBody)
+	responseBody, statusCode, err := self.doRawHttpRequest(path, requestType, requestBody)
 	if err != nil {
-		err := errors.New(fmt.Sprintf("Failed to get proper HTTP response from SoftLayer API"))
+		err := errors.New(fmt.Sprintf("Failed to get proper HTTP response from SoftLayer API: %s", err))
 		return nil, err
 	}
 
@@ -121,9 +420,57 @@ func (self SoftlayerClient) doHttpRequest(path string, requestType string, reque
 		return nil, err
 	}
 
+	if apiError, ok := decodedResponse["error"]; ok && IsHttpErrorCode(statusCode) {
+		code, _ := decodedResponse["code"].(string)
+		return nil, &SoftlayerAPIError{
+			HttpStatusCode: statusCode,
+			Message:        fmt.Sprintf("%v", apiError),
+			Code:           code,
+		}
+	}
+
 	return decodedResponse, nil
 }
 
+// ValidateJson reports an error if s is not well-formed JSON. An empty s
+// means "no filter" and is accepted. It's used to catch a malformed
+// objectFilter before it's sent to SoftLayer, where it would otherwise come
+// back as an opaque API error.
+func ValidateJson(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	if !json.Valid([]byte(s)) {
+		return errors.New(fmt.Sprintf("Invalid objectFilter JSON: %s", s))
+	}
+
+	return nil
+}
+
+// doHttpRequestWithFilter is doHttpRequest plus SoftLayer's objectFilter and
+// objectMask query parameters, which let a caller cut a GET down to just the
+// fields it needs instead of pulling back the whole object graph.
+func (self SoftlayerClient) doHttpRequestWithFilter(path string, filter string, mask string, requestType string, requestBody *bytes.Buffer) (map[string]interface{}, error) {
+	if err := ValidateJson(filter); err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	if filter != "" {
+		query.Set("objectFilter", filter)
+	}
+	if mask != "" {
+		query.Set("objectMask", mask)
+	}
+
+	if encoded := query.Encode(); encoded != "" {
+		path = fmt.Sprintf("%s?%s", path, encoded)
+	}
+
+	return self.doHttpRequest(path, requestType, requestBody)
+}
+
 func (self SoftlayerClient) CreateInstance(instance InstanceType) (map[string]interface{}, error) {
 	// SoftLayer API puts some limitations on hostname and domain fields of the request
 	validName, err := regexp.Compile("[^A-Za-z0-9\\-\\.]+")
@@ -134,17 +481,110 @@ func (self SoftlayerClient) CreateInstance(instance InstanceType) (map[string]in
 	instance.HostName = validName.ReplaceAllString(instance.HostName, "")
 	instance.Domain = validName.ReplaceAllString(instance.Domain, "")
 
-	requestBody := self.generateRequestBody("builder/softlayer/templates/virtual_guest/createObject.json", instance)
+	requestParams := SoftLayer_Virtual_Guest_Template_Parameters{
+		Parameters: []softLayerVirtualGuestTemplate{
+			{
+				Hostname:                     instance.HostName,
+				Domain:                       instance.Domain,
+				StartCpus:                    instance.Cpus,
+				MaxMemory:                    instance.Memory,
+				Datacenter:                   softLayerLocation{Name: instance.Datacenter},
+				HourlyBillingFlag:            instance.HourlyBillingFlag,
+				LocalDiskFlag:                instance.LocalDiskFlag,
+				NetworkComponents:            []softLayerNetworkComponent{{MaxSpeed: instance.NetworkSpeed}},
+				SshKeys:                      []softLayerIdReference{{Id: instance.ProvisioningSshKeyId}},
+				BlockDeviceTemplateGroup:     &softLayerGlobalIdentifier{GlobalIdentifier: instance.BaseImageId},
+				OperatingSystemReferenceCode: instance.BaseOsCode,
+			},
+		},
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := self.doHttpRequest("SoftLayer_Virtual_Guest/createObject", "POST", requestBody)
 	if err != nil {
-		return nil, nil
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// PlaceOrder provisions an instance through SoftLayer_Product_Order/placeOrder
+// instead of the plain createObject call, which is the only way to request
+// extra block devices, pin the instance to a specific VLAN, or build it on
+// the private network only.
+func (self SoftlayerClient) PlaceOrder(instance InstanceType) (map[string]interface{}, error) {
+	validName, err := regexp.Compile("[^A-Za-z0-9\\-\\.]+")
+	if err != nil {
+		return nil, err
+	}
+
+	instance.HostName = validName.ReplaceAllString(instance.HostName, "")
+	instance.Domain = validName.ReplaceAllString(instance.Domain, "")
+
+	blockDevices := make([]softLayerOrderBlockDevice, 0, len(instance.BlockDevices)+1)
+	blockDevices = append(blockDevices, softLayerOrderBlockDevice{
+		Device:    "0",
+		DiskImage: softLayerDiskImageCapacity{Capacity: instance.DiskCapacity},
+	})
+	for _, blockDevice := range instance.BlockDevices {
+		blockDevices = append(blockDevices, softLayerOrderBlockDevice{
+			Device:    blockDevice.Device,
+			DiskImage: softLayerDiskImageCapacity{Capacity: blockDevice.Capacity},
+		})
+	}
+
+	virtualGuest := softLayerOrderVirtualGuest{
+		Hostname:                     instance.HostName,
+		Domain:                       instance.Domain,
+		StartCpus:                    instance.Cpus,
+		MaxMemory:                    instance.Memory,
+		Datacenter:                   softLayerLocation{Name: instance.Datacenter},
+		LocalDiskFlag:                instance.LocalDiskFlag,
+		PrivateNetworkOnlyFlag:       instance.PrivateNetworkOnlyFlag,
+		PostInstallScriptUri:         instance.PostInstallScriptUri,
+		NetworkComponents:            []softLayerNetworkComponent{{MaxSpeed: instance.NetworkSpeed}},
+		SshKeys:                      []softLayerIdReference{{Id: instance.ProvisioningSshKeyId}},
+		BlockDevices:                 blockDevices,
+		OperatingSystemReferenceCode: instance.BaseOsCode,
+	}
+	if instance.PublicVlanId != 0 {
+		virtualGuest.PrimaryNetworkComponent = &softLayerOrderNetworkComponent{NetworkVlan: softLayerIdReference{Id: float64(instance.PublicVlanId)}}
+	}
+	if instance.PrivateVlanId != 0 {
+		virtualGuest.PrimaryBackendNetworkComponent = &softLayerOrderNetworkComponent{NetworkVlan: softLayerIdReference{Id: float64(instance.PrivateVlanId)}}
 	}
 
-	return data, err
+	requestParams := SoftLayer_Container_Product_Order_Virtual_Guest{
+		Parameters: []softLayerProductOrder{
+			{
+				ComplexType:      "SoftLayer_Container_Product_Order_Virtual_Guest",
+				PackageType:      "VIRTUAL_SERVER_INSTANCE",
+				Quantity:         1,
+				UseHourlyPricing: instance.HourlyBillingFlag,
+				VirtualGuests:    []softLayerOrderVirtualGuest{virtualGuest},
+			},
+		},
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := self.doHttpRequest("SoftLayer_Product_Order/placeOrder", "POST", requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
 }
 
 func (self SoftlayerClient) DestroyInstance(instanceId string) error {
-	response, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s.json", instanceId), "DELETE", new(bytes.Buffer))
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s.json", instanceId), "DELETE", new(bytes.Buffer))
 
 	log.Printf("Deleted an Instance with id (%s), response: %s", instanceId, response)
 	// Process response for success?
@@ -153,18 +593,27 @@ func (self SoftlayerClient) DestroyInstance(instanceId string) error {
 }
 
 func (self SoftlayerClient) UploadSshKey(label string, publicKey string) (keyId float64, err error) {
-	templateRawData := map[string]string{"PublicKey": publicKey, "Label": label}
-	requestBody := self.generateRequestBody("builder/softlayer/templates/security_ssh_key/createObject.json", templateRawData)
+	requestParams := SoftLayer_Shh_Key_Parameters{
+		Parameters: []softLayerSecuritySshKey{
+			{Key: publicKey, Label: label},
+		},
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return 0, err
+	}
+
 	data, err := self.doHttpRequest("SoftLayer_Security_Ssh_Key/createObject", "POST", requestBody)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 
-	return data["id"].(float64), err
+	return data["id"].(float64), nil
 }
 
 func (self SoftlayerClient) DestroySshKey(keyId float64) error {
-	response, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Security_Ssh_Key/%v.json", int(keyId)), "DELETE", new(bytes.Buffer))
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Security_Ssh_Key/%v.json", int(keyId)), "DELETE", new(bytes.Buffer))
 
 	log.Printf("Deleted an SSH Key with id (%v), response: %s", keyId, response)
 	// Process response for success?
@@ -173,9 +622,9 @@ func (self SoftlayerClient) DestroySshKey(keyId float64) error {
 }
 
 func (self SoftlayerClient) getInstancePublicIp(instanceId string) (string, error) {
-	response, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getPrimaryIpAddress.json", instanceId), "GET", nil)
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getPrimaryIpAddress.json", instanceId), "GET", nil)
 	if err != nil {
-		return "", nil
+		return "", err
 	}
 
 	var validIp = regexp.MustCompile(`[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}`)
@@ -185,18 +634,26 @@ func (self SoftlayerClient) getInstancePublicIp(instanceId string) (string, erro
 }
 
 func (self SoftlayerClient) captureImage(instanceId string, imageName string, imageDescription string) (map[string]interface{}, error) {
-	templateRawData := map[string]string{"ImageDescription": imageDescription, "ImageName": imageName}
-	requestBody := self.generateRequestBody("builder/softlayer/templates/virtual_guest/captureImage.json", templateRawData)
+	requestParams := SoftLayer_Container_Disk_Image_Capture_Template{
+		Name:        imageName,
+		Description: imageDescription,
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := self.doHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/captureImage.json", instanceId), "POST", requestBody)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
-	return data, err
+	return data, nil
 }
 
 func (self SoftlayerClient) destroyImage(imageId string, datacenterName string) error {
-	response, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s.json", imageId), "DELETE", new(bytes.Buffer))
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s.json", imageId), "DELETE", new(bytes.Buffer))
 
 	log.Printf("Deleted an image with id (%s), response: %s", imageId, response)
 	// Process response for success?
@@ -205,19 +662,22 @@ func (self SoftlayerClient) destroyImage(imageId string, datacenterName string)
 }
 
 func (self SoftlayerClient) isInstantsReady(instanceId string) (bool, error) {
-	powerData, err := self.doHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getPowerState.json", instanceId), "GET", nil)
+	data, err := self.doHttpRequestWithFilter(
+		fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getObject.json", instanceId),
+		"",
+		"mask[powerState,activeTransaction]",
+		"GET",
+		nil,
+	)
 	if err != nil {
-		return false, nil
+		return false, err
 	}
-	isPowerOn := powerData["keyName"].(string) == "RUNNING"
 
-	transactionData, err := self.doHttpRequest(fmt.Sprintf("SoftLayer_Virtual_Guest/%s/getActiveTransaction.json", instanceId), "GET", nil)
-	if err != nil {
-		return false, nil
-	}
-	noTransactions := len(transactionData) == 0
+	powerState, _ := data["powerState"].(map[string]interface{})
+	isPowerOn := powerState != nil && powerState["keyName"] == "RUNNING"
+	noTransactions := data["activeTransaction"] == nil
 
-	return isPowerOn && noTransactions, err
+	return isPowerOn && noTransactions, nil
 }
 
 func (self SoftlayerClient) waitForInstanceReady(instanceId string, timeout time.Duration) error {
@@ -264,4 +724,166 @@ func (self SoftlayerClient) waitForInstanceReady(instanceId string, timeout time
 		err := fmt.Errorf("Timeout while waiting to for the instance to become ready")
 		return err
 	}
-}
\ No newline at end of file
+}
+
+// CreateHardware provisions a bare-metal server through
+// SoftLayer_Product_Order/placeOrder with a BARE_METAL_CORE package, the
+// same order flow used for virtual guests in PlaceOrder but for
+// SoftLayer_Hardware instead of SoftLayer_Virtual_Guest.
+func (self SoftlayerClient) CreateHardware(hardware HardwareType) (map[string]interface{}, error) {
+	validName, err := regexp.Compile("[^A-Za-z0-9\\-\\.]+")
+	if err != nil {
+		return nil, err
+	}
+
+	hardware.HostName = validName.ReplaceAllString(hardware.HostName, "")
+	hardware.Domain = validName.ReplaceAllString(hardware.Domain, "")
+
+	hardDrives := make([]softLayerOrderBlockDevice, len(hardware.HardDrives))
+	for i, hardDrive := range hardware.HardDrives {
+		hardDrives[i] = softLayerOrderBlockDevice{
+			Device:    hardDrive.Device,
+			DiskImage: softLayerDiskImageCapacity{Capacity: hardDrive.Capacity},
+		}
+	}
+
+	requestParams := SoftLayer_Container_Product_Order_Hardware_Server{
+		Parameters: []softLayerHardwareProductOrder{
+			{
+				ComplexType: "SoftLayer_Container_Product_Order_Hardware_Server",
+				PackageType: "BARE_METAL_CORE",
+				PackageId:   hardware.PackageId,
+				PresetId:    hardware.PresetId,
+				Quantity:    1,
+				Hardware: []softLayerOrderHardware{
+					{
+						Hostname:                     hardware.HostName,
+						Domain:                       hardware.Domain,
+						Datacenter:                   softLayerLocation{Name: hardware.Datacenter},
+						ProcessorCoreAmount:          hardware.ProcessorCoreAmount,
+						MemoryCapacity:               hardware.Memory,
+						HourlyBillingFlag:            hardware.HourlyBillingFlag,
+						SshKeys:                      []softLayerIdReference{{Id: hardware.ProvisioningSshKeyId}},
+						HardDrives:                   hardDrives,
+						OperatingSystemReferenceCode: hardware.BaseOsCode,
+					},
+				},
+			},
+		},
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := self.doHttpRequest("SoftLayer_Product_Order/placeOrder", "POST", requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (self SoftlayerClient) DestroyHardware(hardwareId string) error {
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Hardware/%s.json", hardwareId), "DELETE", new(bytes.Buffer))
+
+	log.Printf("Deleted Hardware with id (%s), response: %s", hardwareId, response)
+	// Process response for success?
+
+	return err
+}
+
+func (self SoftlayerClient) getHardwarePublicIp(hardwareId string) (string, error) {
+	response, _, err := self.doRawHttpRequest(fmt.Sprintf("SoftLayer_Hardware/%s/getPrimaryIpAddress.json", hardwareId), "GET", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var validIp = regexp.MustCompile(`[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}\.[0-9]{1,4}`)
+	ipAddress := validIp.Find(response)
+
+	return string(ipAddress), nil
+}
+
+func (self SoftlayerClient) captureHardwareImage(hardwareId string, imageName string, imageDescription string) (map[string]interface{}, error) {
+	requestParams := SoftLayer_Container_Disk_Image_Capture_Template{
+		Name:        imageName,
+		Description: imageDescription,
+	}
+
+	requestBody, err := self.marshalRequestBody(requestParams)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := self.doHttpRequest(fmt.Sprintf("SoftLayer_Hardware/%s/captureImage.json", hardwareId), "POST", requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (self SoftlayerClient) isHardwareReady(hardwareId string) (bool, error) {
+	transactionData, err := self.doHttpRequest(fmt.Sprintf("SoftLayer_Hardware/%s/getActiveTransaction.json", hardwareId), "GET", nil)
+	if err != nil {
+		return false, err
+	}
+
+	return len(transactionData) == 0, nil
+}
+
+// waitForHardwareReady polls the same way waitForInstanceReady does, but
+// callers should pass a much larger timeout (see DefaultHardwareReadyTimeout)
+// since bare-metal provisioning routinely takes 1-4 hours. A timeout of zero
+// or less uses DefaultHardwareReadyTimeout.
+func (self SoftlayerClient) waitForHardwareReady(hardwareId string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultHardwareReadyTimeout
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	result := make(chan error, 1)
+	go func() {
+		attempts := 0
+		for {
+			attempts += 1
+
+			//log.Printf("Checking hardware status... (attempt: %d)", attempts)
+			isReady, err := self.isHardwareReady(hardwareId)
+			if err != nil {
+				result <- err
+				return
+			}
+
+			if isReady {
+				result <- nil
+				return
+			}
+
+			// Wait 3 seconds in between
+			time.Sleep(3 * time.Second)
+
+			// Verify we shouldn't exit
+			select {
+			case <-done:
+				// We finished, so just exit the goroutine
+				return
+			default:
+				// Keep going
+			}
+		}
+	}()
+
+	log.Printf("Waiting for up to %d seconds for hardware to become ready", timeout/time.Second)
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		err := fmt.Errorf("Timeout while waiting to for the hardware to become ready")
+		return err
+	}
+}